@@ -0,0 +1,142 @@
+package feedbot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/fsnakes/feedbot/router"
+)
+
+// ACLEntry grants a principal (a role or a user) permission to invoke a command
+// scope in a guild. Scopes are dotted, so an entry for "set" also grants every
+// "set.*" subcommand (e.g. "set.embed"), while an entry for "set.embed" grants only
+// that one.
+type ACLEntry struct {
+	ID        int
+	GuildID   string
+	Principal string // "u:<userID>" or "r:<roleID>", matching the "u:"/"c:" convention setContact already uses
+	Command   string
+}
+
+// checkPrivilege authorizes ctx's invoker to run command. If the guild has any ACL
+// entries, only those entries decide; ADMINISTRATOR alone no longer grants access.
+// A guild with no ACL entries falls back to requiring ADMINISTRATOR.
+func checkPrivilege(ctx *context, command string) (bool, error) {
+	entries, err := ctx.bot.c.ListACL(ctx.GuildID())
+	if err != nil {
+		return false, err
+	}
+
+	var ok bool
+	if len(entries) == 0 {
+		ok, err = memberHasPermission(ctx.s, ctx.GuildID(), ctx.ChannelID(), ctx.AuthorID(), discordgo.PermissionAdministrator)
+	} else {
+		ok, err = aclAllows(ctx, entries, command)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if !ok {
+		if err := ctx.Reply(adminOnly); err != nil {
+			return false, err
+		}
+	}
+	return ok, nil
+}
+
+// aclAllows reports whether any entry's principal matches the invoker (directly, or
+// via one of their roles) and its Command covers the requested command scope.
+func aclAllows(ctx *context, entries []ACLEntry, command string) (bool, error) {
+	member, err := ctx.s.State.Member(ctx.GuildID(), ctx.AuthorID())
+	if err != nil {
+		if member, err = ctx.s.GuildMember(ctx.GuildID(), ctx.AuthorID()); err != nil {
+			return false, err
+		}
+	}
+
+	for _, e := range entries {
+		if !scopeMatches(e.Command, command) {
+			continue
+		}
+		if e.Principal == "u:"+member.User.ID {
+			return true, nil
+		}
+		for _, roleID := range member.Roles {
+			if e.Principal == "r:"+roleID {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// scopeMatches reports whether granted covers requested: either an exact match, or
+// granted is a dotted prefix of requested (so "set" covers "set.embed").
+func scopeMatches(granted, requested string) bool {
+	return granted == requested || strings.HasPrefix(requested, granted+".")
+}
+
+// memberHasPermission reports whether userID holds permission in channelID, taking
+// into account their roles (including @everyone), and any channel-specific
+// permission overwrites. Permissions must be compared as int64: discordgo's bitmask
+// no longer fits in a plain int, and truncating it silently drops the high bits that
+// ADMINISTRATOR and the newer permission flags live in.
+func memberHasPermission(s *discordgo.Session, guildID, channelID, userID string, permission int64) (bool, error) {
+	perms, err := s.State.UserChannelPermissions(userID, channelID)
+	if err != nil {
+		return false, err
+	}
+	return perms&discordgo.PermissionAdministrator != 0 || perms&permission != 0, nil
+}
+
+// set acl <role|user> <add|remove> <principal> [command]
+func setACLCmd(ctx router.Context, args router.Values) error {
+	c := ctx.(*context)
+	kind, action := args.String("kind"), args.String("action")
+
+	principal, err := parsePrincipal(kind, args.String("principal"))
+	if err != nil {
+		return c.Reply(err.Error())
+	}
+
+	command := args.String("command")
+	switch action {
+	case "add":
+		if command == "" {
+			return c.Reply("a command is required when adding an ACL entry.")
+		}
+		if err := c.bot.c.AddACLEntry(c.GuildID(), principal, command); err != nil {
+			return err
+		}
+		return c.Reply(fmt.Sprintf("granted %s access to: %s", principal, command))
+	case "remove":
+		if command == "" {
+			if err := c.bot.c.RemoveACLPrincipal(c.GuildID(), principal); err != nil {
+				return err
+			}
+			return c.Reply(fmt.Sprintf("removed all ACL entries for %s", principal))
+		}
+		if err := c.bot.c.RemoveACLEntry(c.GuildID(), principal, command); err != nil {
+			return err
+		}
+		return c.Reply(fmt.Sprintf("revoked %s access to: %s", principal, command))
+	default:
+		return c.Reply("action must be one of add|remove")
+	}
+}
+
+// parsePrincipal builds the "r:<id>"/"u:<id>" principal format ACLEntry.Principal
+// uses. id has already been resolved from a role/user mention or a bare numeric ID by
+// router.MentionableArg; kind (itself a validated EnumArg) says which one it is.
+func parsePrincipal(kind, id string) (string, error) {
+	switch kind {
+	case "role":
+		return "r:" + id, nil
+	case "user":
+		return "u:" + id, nil
+	default:
+		return "", fmt.Errorf("principal kind must be one of role|user")
+	}
+}
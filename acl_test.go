@@ -0,0 +1,25 @@
+package feedbot
+
+import "testing"
+
+func TestScopeMatches(t *testing.T) {
+	tests := []struct {
+		granted, requested string
+		want               bool
+	}{
+		{"set", "set", true},
+		{"set", "set.embed", true},
+		{"set", "set.filter.add", true},
+		{"set.embed", "set", false},
+		{"set.embed", "set.embed", true},
+		{"set.embed", "set.webhook", false},
+		{"add", "add", true},
+		{"add", "addendum", false},
+	}
+
+	for _, tt := range tests {
+		if got := scopeMatches(tt.granted, tt.requested); got != tt.want {
+			t.Errorf("scopeMatches(%q, %q) = %v, want %v", tt.granted, tt.requested, got, tt.want)
+		}
+	}
+}
@@ -3,29 +3,91 @@ package feedbot
 import (
 	"database/sql"
 	"fmt"
-	"regexp"
 	"runtime/debug"
-	"strconv"
 	"strings"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/fsnakes/feedbot/router"
+	"github.com/fsnakes/feedbot/transmitter"
 	"github.com/pkg/errors"
 )
 
 type context struct {
-	bot  *Bot
-	s    *discordgo.Session
-	m    *discordgo.MessageCreate
-	args []string
+	bot *Bot
+	s   *discordgo.Session
+	m   *discordgo.MessageCreate
+	i   *discordgo.InteractionCreate
 }
 
-// Reply sends a message to the source channel
+// ChannelID returns the channel the command was invoked from, regardless of whether
+// it arrived as a legacy text command or a slash command.
+func (c *context) ChannelID() string {
+	if c.i != nil {
+		return c.i.ChannelID
+	}
+	return c.m.ChannelID
+}
+
+// GuildID returns the guild the command was invoked from, regardless of whether
+// it arrived as a legacy text command or a slash command.
+func (c *context) GuildID() string {
+	if c.i != nil {
+		return c.i.GuildID
+	}
+	return c.m.GuildID
+}
+
+// AuthorID returns the ID of the user who invoked the command.
+func (c *context) AuthorID() string {
+	if c.i != nil {
+		return interactionMember(c.i).User.ID
+	}
+	return c.m.Author.ID
+}
+
+// Reply sends a message to the source channel, or, for a slash command, responds to
+// the interaction that invoked it.
 func (c *context) Reply(m string) error {
+	if c.i != nil {
+		return c.s.InteractionRespond(c.i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{Content: m},
+		})
+	}
 	_, err := c.s.ChannelMessageSend(c.m.ChannelID, m)
 	return err
 }
 
-type commandHandler = func(c *context) error
+// ReplyEmbed sends embed with components attached, returning the resulting message
+// so the caller can track it (e.g. for paginated list output).
+func (c *context) ReplyEmbed(embed *discordgo.MessageEmbed, components []discordgo.MessageComponent) (*discordgo.Message, error) {
+	if c.i != nil {
+		err := c.s.InteractionRespond(c.i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Embeds:     []*discordgo.MessageEmbed{embed},
+				Components: components,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return c.s.InteractionResponse(c.i.Interaction)
+	}
+	return c.s.ChannelMessageSendComplex(c.m.ChannelID, &discordgo.MessageSend{
+		Embed:      embed,
+		Components: components,
+	})
+}
+
+// interactionMember returns the member who triggered an interaction, whether it
+// happened in a guild (Member set) or a DM (User set).
+func interactionMember(i *discordgo.InteractionCreate) *discordgo.Member {
+	if i.Member != nil {
+		return i.Member
+	}
+	return &discordgo.Member{User: i.User}
+}
 
 var mentionPrefix = "<@0>"
 var mentionPrefixLen = len(mentionPrefix)
@@ -33,15 +95,136 @@ var prefix = "/feed:"
 var prefixLen = len(prefix)
 var owner = "<@0>"
 
-var channelRegex = regexp.MustCompile(`<#\d+>`)
+// cmdRouter declares every command's name, subcommands, typed arguments, and
+// required privilege scope in one place, and drives both onMessageCreate's legacy
+// text parsing and onInteractionCreate's slash-command dispatch from it. See
+// router.Command for the shape each entry below takes.
+var cmdRouter = buildRouter()
+
+func buildRouter() *router.Router {
+	rt := router.New(routerAuthorize, routerOnError, routerOnPanic)
+	rt.Register(
+		&router.Command{Name: "help", Description: "print the feedbot help message", Public: true, Handler: helpCmd},
+		&router.Command{
+			Name:        "add",
+			Description: "add an RSS feed",
+			Args: []router.Arg{
+				router.StringArg{Name: "uri", Description: "the feed's URI", Required: true},
+				router.ChannelArg{Name: "channel", Description: "channel to post updates in (defaults to the current channel)"},
+			},
+			Handler: addCmd,
+		},
+		&router.Command{
+			Name:        "remove",
+			Description: "remove a feed subscription by ID",
+			Args:        []router.Arg{router.SubscriptionArg{Name: "id", Required: true, RequireGuildMatch: true}},
+			Handler:     removeCmd,
+		},
+		&router.Command{
+			Name:        "list",
+			Description: "list the feed subscriptions active in this guild",
+			Handler:     listCmd,
+		},
+		&router.Command{
+			Name:        "set",
+			Description: "change feedbot's configuration for this guild",
+			Subcommands: []*router.Command{
+				{
+					Name:        "channel",
+					Description: "change which channel a subscription writes to",
+					Args: []router.Arg{
+						router.SubscriptionArg{Name: "id", Required: true, RequireGuildMatch: true},
+						router.ChannelArg{Name: "channel", Description: "channel to post updates in (defaults to the current channel)"},
+					},
+					Handler: setChannelCmd,
+				},
+				{
+					Name:        "contact",
+					Description: "change the guild's emergency contact",
+					Args: []router.Arg{
+						router.UserArg{Name: "user", Description: "user to contact"},
+						router.ChannelArg{Name: "channel", Description: "channel to contact"},
+					},
+					Handler: setContactCmd,
+				},
+				{
+					Name:        "embed",
+					Description: "enable or disable embeds",
+					Args: []router.Arg{
+						router.EnumArg{Name: "value", Description: "on|off|inherit", Required: true, Choices: []string{"on", "off", "inherit"}},
+						router.SubscriptionArg{Name: "id", RequireGuildMatch: true},
+					},
+					Handler: setEmbedCmd,
+				},
+				{
+					Name:        "webhook",
+					Description: "enable or disable webhooks",
+					Args: []router.Arg{
+						router.EnumArg{Name: "value", Description: "on|off|inherit", Required: true, Choices: []string{"on", "off", "inherit"}},
+						router.SubscriptionArg{Name: "id", RequireGuildMatch: true},
+					},
+					Handler: setWebhookCmd,
+				},
+				{
+					Name:        "filter",
+					Description: "manage per-subscription content filters",
+					Subcommands: []*router.Command{
+						{
+							Name:        "add",
+							Description: "add a filter to a subscription",
+							Args: []router.Arg{
+								router.SubscriptionArg{Name: "id", Required: true, RequireGuildMatch: true},
+								router.EnumArg{Name: "kind", Description: "include|exclude", Required: true, Choices: []string{"include", "exclude"}},
+								router.StringArg{Name: "pattern", Description: "plain substring or /regex/flags", Required: true},
+							},
+							Handler: setFilterAddCmd,
+						},
+						{
+							Name:        "remove",
+							Description: "remove a filter from a subscription",
+							Args: []router.Arg{
+								router.SubscriptionArg{Name: "id", Required: true, RequireGuildMatch: true},
+								router.IntegerArg{Name: "filterID", Description: "the filter ID", Required: true},
+							},
+							Handler: setFilterRemoveCmd,
+						},
+						{
+							Name:        "list",
+							Description: "list the filters active on a subscription",
+							Args:        []router.Arg{router.SubscriptionArg{Name: "id", Required: true, RequireGuildMatch: true}},
+							Handler:     setFilterListCmd,
+						},
+					},
+				},
+				{
+					Name:        "acl",
+					Description: "let a role or user run commands without full ADMINISTRATOR",
+					Args: []router.Arg{
+						router.EnumArg{Name: "kind", Description: "role|user", Required: true, Choices: []string{"role", "user"}},
+						router.EnumArg{Name: "action", Description: "add|remove", Required: true, Choices: []string{"add", "remove"}},
+						router.MentionableArg{Name: "principal", Description: "role or user mention", Required: true},
+						router.StringArg{Name: "command", Description: "command scope, e.g. set.embed; omit to affect every command on remove"},
+					},
+					Handler: setACLCmd,
+				},
+			},
+		},
+	)
+	return rt
+}
+
+// routerAuthorize adapts checkPrivilege to router.Authorizer.
+func routerAuthorize(ctx router.Context, scope string) (bool, error) {
+	return checkPrivilege(ctx.(*context), scope)
+}
 
-var mux = map[string]commandHandler{
-	"help":        help,
-	"add":         add,
-	"remove":      remove,
-	"list":        list,
-	"set":         set,
-	"dbg~migrate": dbgMigrate,
+func routerOnError(path string, err error) {
+	l.Println(fmt.Sprintf("cmd:%s err:%+v", path, err))
+}
+
+func routerOnPanic(path string, recovered interface{}) {
+	l.Println(fmt.Sprintf("cmd:%s pnc:%+v", path, recovered))
+	debug.PrintStack()
 }
 
 // onReady handles the Discord READY event
@@ -54,6 +237,24 @@ func (bot *Bot) onReady(s *discordgo.Session, m *discordgo.Ready) {
 		panic(err)
 	}
 	owner = apps.Owner.ID
+
+	// bot.c doubles as the transmitter's Store, so its posted-message cache survives
+	// a restart.
+	t, err := transmitter.New(s, bot.c)
+	if err != nil {
+		l.Println(fmt.Sprintf("err creating transmitter: %+v", err))
+		return
+	}
+	tx = t
+}
+
+// Close persists the webhook transmitter's posted-message cache. Callers should
+// invoke this during graceful shutdown, before the process exits.
+func (bot *Bot) Close() error {
+	if tx == nil {
+		return nil
+	}
+	return tx.Close()
 }
 
 // onMessageCreate handles the Discord MESSAGE_CREATE event
@@ -75,33 +276,29 @@ func (bot *Bot) onMessageCreate(s *discordgo.Session, m *discordgo.MessageCreate
 	if len(parts) < 1 {
 		return
 	}
-	f, ok := mux[parts[0]]
-	if !ok {
-		return
-	}
-
+	name := parts[0]
 	var args []string
 	if len(parts) > 1 {
 		args = parts[1:]
 	}
 
-	defer func() {
-		if err := recover(); err != nil {
-			l.Println(fmt.Sprintf("cmd:%s pnc:%+v", parts[0], err))
-			debug.PrintStack()
-		}
-	}()
+	ctx := &context{bot: bot, s: s, m: m}
 
-	ctx := &context{
-		bot:  bot,
-		s:    s,
-		m:    m,
-		args: args,
-	}
-	err := f(ctx)
-	if err != nil {
-		l.Println(fmt.Sprintf("cmd:%s err:%+v", parts[0], err))
+	// dbg~migrate is an owner-only escape hatch, not subject to the per-guild ACL
+	// the rest of the commands go through, so it stays outside cmdRouter.
+	if name == "dbg~migrate" {
+		defer func() {
+			if err := recover(); err != nil {
+				routerOnPanic(name, err)
+			}
+		}()
+		if err := dbgMigrate(ctx); err != nil {
+			routerOnError(name, err)
+		}
+		return
 	}
+
+	cmdRouter.Dispatch(ctx, name, args)
 }
 
 const helpText = `
@@ -116,6 +313,13 @@ const helpText = `
 - set contact <user|channel>: set the emergency contact for this guild; defaults to the server owner
 - set embed <on|off|inherit> [id]: enable or disable embeds for this guild; optionally specifying a feed to change this behavior for
 - set webhook <on|off|inherit> [id]: enable or disable webhooks for this guild, optionally specifying a feed to change this behavior for
+- set filter add <id> <include|exclude> <pattern>: only dispatch (or drop) items matching pattern for subscription <id>; pattern may be a plain substring or /regex/flags
+- set filter remove <id> <filterID>: remove a previously-added filter from subscription <id>
+- set filter list <id>: list the filters active on subscription <id>
+- set acl <role|user> add <principal> <command>: let a role or user run the given command (e.g. add, set, set.embed) without needing ADMINISTRATOR
+- set acl <role|user> remove <principal> [command]: revoke the given command, or every command if none is specified
+
+both commands and slash commands accept the same arguments; use either /feed: or a mention as a prefix for text commands.
 
 the inherit flag may only be used when specifying a feed-specific overwrite!
 
@@ -124,7 +328,8 @@ every 60 minutes, feedbot will ping the feeds its users have specified. for feed
 will find every discord channel with a subscription, and send an update.
 
 **permissions:**
-feedbot will only respect users who poesess the **ADMINISTRATOR** permission in a guild.discordgo
+by default, feedbot will only respect users who poesess the **ADMINISTRATOR** permission in a guild. once you add
+a set acl entry, feedbot switches to that allow-list for every command instead.
 
 feedbot by default only requires **READ MESSAGES** and **SEND MESSAGES**.
 
@@ -135,373 +340,217 @@ if webhooks are enabled for a feed, the **MANAGE WEBHOOKS** permission must be g
 if a permission is missing, or a feed is broken, feedbot will notify the emergency contact.
 `
 
-// help
-func help(ctx *context) error {
+func helpCmd(ctx router.Context, args router.Values) error {
 	return ctx.Reply(helpText)
 }
 
-// add <uri> [channel]
-func add(ctx *context) error {
-	ok, err := checkPrivilege(ctx)
-	if err != nil {
-		return errors.WithStack(err)
-	}
-	if !ok {
-		return nil
-	}
+func addCmd(ctx router.Context, args router.Values) error {
+	c := ctx.(*context)
 
-	if l := len(ctx.args); l < 1 || l > 2 {
-		return ctx.Reply("**usage:** `add <uri> [channel]`; please omit spaces from arguments!")
-	}
-	uri := ctx.args[0]
-	var channel string
-	if len(ctx.args) == 2 {
-		c := ctx.args[1]
-		if !channelRegex.MatchString(c) {
-			return ctx.Reply("when specifying a channel ID, please use a #channel mention!")
-		}
-		// <#...>
-		channel = c[2 : len(c)-1]
-	} else {
-		channel = ctx.m.ChannelID
+	channel := args.String("channel")
+	if channel == "" {
+		channel = c.ChannelID()
 	}
 
-	feed, err := ctx.bot.c.GetOrCreateFeed(uri)
+	feed, err := c.bot.c.GetOrCreateFeed(args.String("uri"))
 	if err != nil {
 		return err
 	}
-	sub, err := ctx.bot.c.AddSubscription(channel, ctx.m.GuildID, feed.ID)
+	sub, err := c.bot.c.AddSubscription(channel, c.GuildID(), feed.ID)
 	if err == ErrSubExists {
-		return ctx.Reply(fmt.Sprintf("this subscription (#%d) already exists!", sub.ID))
+		return c.Reply(fmt.Sprintf("this subscription (#%d) already exists!", sub.ID))
 	} else if err != nil {
 		return err
 	}
 
-	return ctx.Reply(fmt.Sprintf("subscription #%d created!", sub.ID))
+	return c.Reply(fmt.Sprintf("subscription #%d created!", sub.ID))
 }
 
-// remove <id>
-func remove(ctx *context) error {
-	ok, err := checkPrivilege(ctx)
-	if err != nil {
-		return err
-	}
-	if !ok {
-		return nil
-	}
+func removeCmd(ctx router.Context, args router.Values) error {
+	c := ctx.(*context)
 
-	if len(ctx.args) != 1 {
-		return ctx.Reply("**usage:** `remove <id>`; please omit spaces from arguments?!")
-	}
-	id, err := strconv.Atoi(ctx.args[0])
-	if err != nil {
-		return ctx.Reply("`id` must be a number!")
-	}
-	sub, err := ctx.bot.c.GetSubscription(id)
-	if err == sql.ErrNoRows {
-		return ctx.Reply("could not find a subscription with that ID, check the list again?")
-	} else if err != nil {
+	sub, err := getOwnedSubscription(c, args.String("id"))
+	if err != nil || sub == nil {
 		return err
 	}
 
-	if sub.GuildID != ctx.m.GuildID {
-		return ctx.Reply(fmt.Sprintf("subscription #%d does not exist in this guild.", id))
+	if err := c.bot.c.DestroySubscription(sub.ID); err != nil {
+		return err
 	}
-
-	err = ctx.bot.c.DestroySubscription(id)
-	return ctx.Reply(fmt.Sprintf("subscription #%d has been deleted.", id))
+	return c.Reply(fmt.Sprintf("subscription #%d has been deleted.", sub.ID))
 }
 
-// list
-func list(ctx *context) error {
-	ok, err := checkPrivilege(ctx)
-	if err != nil {
-		return err
-	}
-	if !ok {
-		return nil
-	}
+func listCmd(ctx router.Context, args router.Values) error {
+	c := ctx.(*context)
 
-	gc, err := ctx.bot.c.GetGuildConfig(ctx.m.GuildID)
+	gc, err := c.bot.c.GetGuildConfig(c.GuildID())
 	if err != nil {
 		return err
 	}
-	subs, err := ctx.bot.c.GetSubscriptions(ctx.m.GuildID)
+	subs, err := c.bot.c.GetSubscriptions(c.GuildID())
 	if err != nil {
 		return err
 	}
 
-	var b strings.Builder
-	b.WriteString(fmt.Sprintf("**Guild Contact:** `%s`\n**Embeds?** %v\n**Webhooks?** %v\n\n",
-		gc.Contact, gc.Embeds, gc.Webhooks))
-
-	b.WriteString("**Sub ID | Channel | Feed URI | Embed? | Webhook?\n\n**")
-	for _, s := range subs {
-		b.WriteString(fmt.Sprintf("%d | <#%s> | `%s` | %v | %v\n",
-			s.ID, s.ChannelID, s.Feed.URI, fmtBool(s.Overwrite.Embeds), fmtBool(s.Overwrite.Webhooks)))
-
-		if b.Len() > 1900 {
-			err = ctx.Reply(b.String())
-			if err != nil {
-				return err
-			}
-			b = strings.Builder{}
-		}
-	}
-
-	return ctx.Reply(b.String())
-}
-
-// set <channel|contact|embed|webhook> [...]
-func set(ctx *context) error {
-	ok, err := checkPrivilege(ctx)
+	pages := listPages(gc, subs)
+	msg, err := c.ReplyEmbed(pages[0], listComponents(0, len(pages)))
 	if err != nil {
 		return err
 	}
-	if !ok {
-		return nil
+	if len(pages) > 1 {
+		startPaginationSession(c.s, msg, c.AuthorID(), pages)
 	}
-
-	if len(ctx.args) == 0 {
-		return ctx.Reply("**usage:** set <channel|contact|embed|webhook> ..., see help command.")
-	}
-	subCommand := ctx.args[0]
-	switch subCommand {
-	case "channel":
-		err = setChannel(ctx)
-	case "contact":
-		err = setContact(ctx)
-	case "embed":
-		err = setEmbed(ctx)
-	case "webhook":
-		err = setWebhook(ctx)
-	default:
-		err = ctx.Reply("subcommand must be one of channel|contact|embed|webhook, see help command.")
-	}
-	return err
+	return nil
 }
 
-// set channel <id> [channel]
-func setChannel(ctx *context) error {
-	if len(ctx.args) < 2 {
-		return ctx.Reply("**usage:** `set channel <id> [channel]`; please omit spaces from arguments?!")
+// listPages renders subs into embed pages of listPageSize subscriptions each, with
+// the guild-wide config summarized on every page.
+func listPages(gc *GuildConfig, subs []*Subscription) []*discordgo.MessageEmbed {
+	summary := fmt.Sprintf("**Guild Contact:** `%s`\n**Embeds?** %v\n**Webhooks?** %v", gc.Contact, gc.Embeds, gc.Webhooks)
+
+	if len(subs) == 0 {
+		return []*discordgo.MessageEmbed{{
+			Title:       "Feed Subscriptions",
+			Description: summary + "\n\nthis guild has no feed subscriptions yet; see the `add` command.",
+		}}
 	}
 
-	var channelID string
-	if len(ctx.args) == 3 {
-		c := ctx.args[2]
-		if !channelRegex.MatchString(c) {
-			return ctx.Reply("when specifying a channel ID, please use a #channel mention!")
+	var pages []*discordgo.MessageEmbed
+	for start := 0; start < len(subs); start += listPageSize {
+		end := start + listPageSize
+		if end > len(subs) {
+			end = len(subs)
 		}
-		// <#...>
-		channelID = c[2 : len(c)-1]
-	} else {
-		channelID = ctx.m.ChannelID
-	}
 
-	id, err := strconv.Atoi(ctx.args[1])
-	if err != nil {
-		return ctx.Reply("`id` must be a number!")
+		embed := &discordgo.MessageEmbed{
+			Title:       "Feed Subscriptions",
+			Description: summary,
+		}
+		for _, s := range subs[start:end] {
+			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+				Name: fmt.Sprintf("#%d — <#%s>", s.ID, s.ChannelID),
+				Value: fmt.Sprintf("`%s`\nembed: %s | webhook: %s",
+					s.Feed.URI, fmtBool(s.Overwrite.Embeds), fmtBool(s.Overwrite.Webhooks)),
+			})
+		}
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("page %d/%d", len(pages)+1, (len(subs)+listPageSize-1)/listPageSize)}
+		pages = append(pages, embed)
 	}
-	sub, err := ctx.bot.c.GetSubscription(id)
-	if err == sql.ErrNoRows {
-		return ctx.Reply("could not find a subscription with that ID, check the list again?")
-	} else if err != nil {
+	return pages
+}
+
+func setChannelCmd(ctx router.Context, args router.Values) error {
+	c := ctx.(*context)
+
+	sub, err := getOwnedSubscription(c, args.String("id"))
+	if err != nil || sub == nil {
 		return err
 	}
 
-	if sub.GuildID != ctx.m.GuildID {
-		return ctx.Reply(fmt.Sprintf("subscription #%d does not exist in this guild.", id))
+	channelID := args.String("channel")
+	if channelID == "" {
+		channelID = c.ChannelID()
 	}
 
-	err = ctx.bot.c.ModifySubscriptionChannel(id, channelID)
-	if err != nil {
+	if err := c.bot.c.ModifySubscriptionChannel(sub.ID, channelID); err != nil {
 		return err
 	}
-
-	return ctx.Reply(fmt.Sprintf("subscription #%d will now write to <#%s>", id, channelID))
+	return c.Reply(fmt.Sprintf("subscription #%d will now write to <#%s>", sub.ID, channelID))
 }
 
-// set contact <user|channel>
-func setContact(ctx *context) error {
-	if len(ctx.args) != 2 {
-		return ctx.Reply("**usage:** `set contact <user|channel>`; please use a user mention, user id, or channel mention, and omit spaces.")
-	}
-	arg := ctx.args[1]
+func setContactCmd(ctx router.Context, args router.Values) error {
+	c := ctx.(*context)
 
 	var id string
-	if channelRegex.MatchString(arg) {
-		// <#...>
-		c := arg[2 : len(arg)-1]
-		id = "c:" + c
-	} else if len(ctx.m.Mentions) > 0 {
-		u := ctx.m.Mentions[0].ID
-		id = "u:" + u
-	} else if _, err := strconv.Atoi(arg); err == nil {
-		id = "u:" + arg
-	} else {
-		return ctx.Reply("contact must be a user mention, user id, or channel mention; not a user name or channel name.")
+	switch {
+	case args.String("channel") != "":
+		id = "c:" + args.String("channel")
+	case args.String("user") != "":
+		id = "u:" + args.String("user")
+	default:
+		return c.Reply("contact must be a user or a channel.")
 	}
 
-	err := ctx.bot.c.ModifyGuildContact(ctx.m.GuildID, id)
-	if err != nil {
+	if err := c.bot.c.ModifyGuildContact(c.GuildID(), id); err != nil {
 		return err
 	}
-	return ctx.Reply("the guild's contact has been changed.")
+	return c.Reply("the guild's contact has been changed.")
 }
 
-// set embed <on|off|inherit> [id]
-func setEmbed(ctx *context) error {
-	if len(ctx.args) < 2 {
-		return ctx.Reply("**usage:** `set embed <on|off|inherit> [id]`")
-	}
-
-	a := ctx.args[1]
-	var val sql.NullBool
-	if a == "on" {
-		val = sql.NullBool{Bool:true, Valid:true}
-	} else if a == "off" {
-		val = sql.NullBool{Bool:false, Valid:true}
-	} else if a == "inherit" {
-		val = sql.NullBool{Valid:false}
-	} else {
-		return ctx.Reply("parameter must be one of on|off")
-	}
+func setEmbedCmd(ctx router.Context, args router.Values) error {
+	c := ctx.(*context)
+	val := parseOnOffInherit(args.String("value"))
 
-	if len(ctx.args) == 2 {
+	if id := args.String("id"); id == "" {
 		if !val.Valid {
-			return ctx.Reply("`inherit` is only a valid flag on overwrites, please specify on|off")
+			return c.Reply("`inherit` is only a valid flag on overwrites, please specify on|off")
 		}
-		err := ctx.bot.c.ModifyGuildEmbeds(ctx.m.GuildID, val.Bool)
-		if err != nil {
+		if err := c.bot.c.ModifyGuildEmbeds(c.GuildID(), val.Bool); err != nil {
 			return err
 		}
 	} else {
-		id, err := strconv.Atoi(ctx.args[2])
-		if err != nil {
-			return ctx.Reply("`id` must be a number!")
-		}
-		sub, err := ctx.bot.c.GetSubscription(id)
-		if err == sql.ErrNoRows {
-			return ctx.Reply("could not find a subscription with that ID, check the list again?")
-		} else if err != nil {
+		sub, err := getOwnedSubscription(c, id)
+		if err != nil || sub == nil {
 			return err
 		}
-
-		if sub.GuildID != ctx.m.GuildID {
-			return ctx.Reply(fmt.Sprintf("subscription #%d does not exist in this guild.", id))
-		}
-
-		err = ctx.bot.c.ModifyOverwriteEmbeds(sub.ID, val)
-		if err != nil {
+		if err := c.bot.c.ModifyOverwriteEmbeds(sub.ID, val); err != nil {
 			return err
 		}
 	}
 
-	if val.Bool {
-		return ctx.Reply("feedbot will now post updates in this guild using embeds, unless overridden elsewhere.")
-	}
-	if val.Valid {
-		return ctx.Reply("feedbot will no longer post updates in this guild using embeds, unless overridden elsewhere.")
-	}
-	return ctx.Reply("feedbot will default to the guild-wide behavior for embeds.")
+	return c.Reply(embedsWebhooksReply("embeds", val))
 }
 
-// set webhook <on|off> [id]
-func setWebhook(ctx *context) error {
-	if len(ctx.args) < 2 {
-		return ctx.Reply("**usage:** `set webhook <on|off> [id]`")
-	}
+func setWebhookCmd(ctx router.Context, args router.Values) error {
+	c := ctx.(*context)
+	val := parseOnOffInherit(args.String("value"))
 
-	a := ctx.args[1]
-	var val sql.NullBool
-	if a == "on" {
-		val = sql.NullBool{Bool:true, Valid:true}
-	} else if a == "off" {
-		val = sql.NullBool{Bool:false, Valid:true}
-	} else if a == "inherit" {
-		val = sql.NullBool{Valid:false}
-	} else {
-		return ctx.Reply("parameter must be one of on|off")
-	}
-
-	if len(ctx.args) == 2 {
+	if id := args.String("id"); id == "" {
 		if !val.Valid {
-			return ctx.Reply("`inherit` is only a valid flag on overwrites, please specify on|off")
+			return c.Reply("`inherit` is only a valid flag on overwrites, please specify on|off")
 		}
-		err := ctx.bot.c.ModifyGuildWebhooks(ctx.m.GuildID, val.Bool)
-		if err != nil {
+		if err := c.bot.c.ModifyGuildWebhooks(c.GuildID(), val.Bool); err != nil {
 			return err
 		}
 	} else {
-		id, err := strconv.Atoi(ctx.args[2])
-		if err != nil {
-			return ctx.Reply("`id` must be a number!")
-		}
-		sub, err := ctx.bot.c.GetSubscription(id)
-		if err == sql.ErrNoRows {
-			return ctx.Reply("could not find a subscription with that ID, check the list again?")
-		} else if err != nil {
+		sub, err := getOwnedSubscription(c, id)
+		if err != nil || sub == nil {
 			return err
 		}
-
-		if sub.GuildID != ctx.m.GuildID {
-			return ctx.Reply(fmt.Sprintf("subscription #%d does not exist in this guild.", id))
-		}
-
-		err = ctx.bot.c.ModifyOverwriteWebhooks(sub.ID, val)
-		if err != nil {
+		if err := c.bot.c.ModifyOverwriteWebhooks(sub.ID, val); err != nil {
 			return err
 		}
 	}
 
-	if val.Bool {
-		return ctx.Reply("feedbot will now post updates in this guild using webhooks, unless overridden elsewhere.")
-	}
-	if val.Valid {
-		return ctx.Reply("feedbot will no longer post updates in this guild using webhooks, unless overridden elsewhere.")
-	}
-	return ctx.Reply("feedbot will default to the guild-wide behavior for webhooks.")
+	return c.Reply(embedsWebhooksReply("webhooks", val))
 }
 
-const adminOnly = "Sorry, feedbot requires the **ADMINISTRATOR** privilege!"
-
-func checkPrivilege(ctx *context) (bool, error) {
-	ok, err := memberHasPermission(ctx.s, ctx.m.GuildID, ctx.m.Author.ID, discordgo.PermissionAdministrator)
-	if err != nil {
-		return false, err
-	}
-	if !ok {
-		if err = ctx.Reply(adminOnly); err != nil {
-			return false, err
-		}
+// parseOnOffInherit converts an EnumArg{"on","off","inherit"} value into the
+// sql.NullBool the DB layer expects; the EnumArg itself guarantees v is one of the
+// three, so there's no error case to handle here.
+func parseOnOffInherit(v string) sql.NullBool {
+	switch v {
+	case "on":
+		return sql.NullBool{Bool: true, Valid: true}
+	case "off":
+		return sql.NullBool{Bool: false, Valid: true}
+	default:
+		return sql.NullBool{Valid: false}
 	}
-	return true, nil
 }
 
-func memberHasPermission(s *discordgo.Session, guildID string, userID string, permission int) (bool, error) {
-	member, err := s.State.Member(guildID, userID)
-	if err != nil {
-		if member, err = s.GuildMember(guildID, userID); err != nil {
-			return false, err
-		}
+func embedsWebhooksReply(feature string, val sql.NullBool) string {
+	if val.Bool {
+		return fmt.Sprintf("feedbot will now post updates in this guild using %s, unless overridden elsewhere.", feature)
 	}
-
-	// Iterate through the role IDs stored in member.Roles
-	// to check permissions
-	for _, roleID := range member.Roles {
-		role, err := s.State.Role(guildID, roleID)
-		if err != nil {
-			return false, err
-		}
-		if role.Permissions&permission != 0 {
-			return true, nil
-		}
+	if val.Valid {
+		return fmt.Sprintf("feedbot will no longer post updates in this guild using %s, unless overridden elsewhere.", feature)
 	}
-
-	return false, nil
+	return fmt.Sprintf("feedbot will default to the guild-wide behavior for %s.", feature)
 }
 
+const adminOnly = "Sorry, feedbot requires the **ADMINISTRATOR** privilege!"
+
 func findChannel(ctx *context, id string) (*discordgo.Channel, error) {
 	channel, err := ctx.s.State.Channel(id)
 	if err != nil {
@@ -546,4 +595,4 @@ func fmtBool(v sql.NullBool) string {
 	} else {
 		return "false"
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,214 @@
+package feedbot
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fsnakes/feedbot/router"
+	"github.com/pkg/errors"
+)
+
+// Filter is a per-subscription include or exclude rule, evaluated against a feed
+// item's title, summary, and category before the item is dispatched.
+type Filter struct {
+	ID             int
+	SubscriptionID int
+	Kind           string // "include" or "exclude"
+	Pattern        string
+}
+
+// compiledFilter caches the parsed form of a Filter's Pattern, so the poller doesn't
+// re-parse or re-compile a regex on every item of every poll.
+type compiledFilter struct {
+	substr string // lowercased plain-substring pattern; empty if re is set
+	re     *regexp.Regexp
+}
+
+var (
+	filterCacheMu sync.RWMutex
+	filterCache   = map[string]*compiledFilter{}
+)
+
+// compilePattern parses pattern as either plain substring or, if wrapped like
+// `/foo/i`, a regex with flags, caching the compiled result by the raw pattern
+// string.
+func compilePattern(pattern string) (*compiledFilter, error) {
+	filterCacheMu.RLock()
+	cf, ok := filterCache[pattern]
+	filterCacheMu.RUnlock()
+	if ok {
+		return cf, nil
+	}
+
+	cf, err := parsePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	filterCacheMu.Lock()
+	filterCache[pattern] = cf
+	filterCacheMu.Unlock()
+	return cf, nil
+}
+
+func parsePattern(pattern string) (*compiledFilter, error) {
+	if len(pattern) >= 2 && pattern[0] == '/' {
+		end := strings.LastIndex(pattern, "/")
+		if end > 0 {
+			expr, flags := pattern[1:end], pattern[end+1:]
+			if flags != "" {
+				expr = fmt.Sprintf("(?%s)%s", flags, expr)
+			}
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, errors.Wrapf(err, "err compiling filter pattern %q", pattern)
+			}
+			return &compiledFilter{re: re}, nil
+		}
+	}
+	return &compiledFilter{substr: strings.ToLower(pattern)}, nil
+}
+
+// Match reports whether title, summary, or category satisfy the compiled pattern.
+func (cf *compiledFilter) Match(title, summary, category string) bool {
+	if cf.re != nil {
+		return cf.re.MatchString(title) || cf.re.MatchString(summary) || cf.re.MatchString(category)
+	}
+	t, s, c := strings.ToLower(title), strings.ToLower(summary), strings.ToLower(category)
+	return strings.Contains(t, cf.substr) || strings.Contains(s, cf.substr) || strings.Contains(c, cf.substr)
+}
+
+// ShouldDispatch decides whether a feed item passes a subscription's filters: it is
+// dropped if any include filters exist and none match, then dropped again if any
+// exclude filter matches.
+func ShouldDispatch(filters []Filter, title, summary, category string) (bool, error) {
+	var includes, excludes []Filter
+	for _, f := range filters {
+		if f.Kind == "include" {
+			includes = append(includes, f)
+		} else {
+			excludes = append(excludes, f)
+		}
+	}
+
+	if len(includes) > 0 {
+		matched := false
+		for _, f := range includes {
+			cf, err := compilePattern(f.Pattern)
+			if err != nil {
+				return false, err
+			}
+			if cf.Match(title, summary, category) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+
+	for _, f := range excludes {
+		cf, err := compilePattern(f.Pattern)
+		if err != nil {
+			return false, err
+		}
+		if cf.Match(title, summary, category) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// set filter add <id> <include|exclude> <pattern>
+func setFilterAddCmd(ctx router.Context, args router.Values) error {
+	c := ctx.(*context)
+
+	sub, err := getOwnedSubscription(c, args.String("id"))
+	if err != nil || sub == nil {
+		return err
+	}
+
+	kind, pattern := args.String("kind"), args.String("pattern")
+	if _, err := compilePattern(pattern); err != nil {
+		return c.Reply(fmt.Sprintf("that pattern didn't compile: %v", err))
+	}
+
+	f, err := c.bot.c.AddFilter(sub.ID, kind, pattern)
+	if err != nil {
+		return err
+	}
+
+	return c.Reply(fmt.Sprintf("filter #%d added: %s items matching `%s`", f.ID, kind, pattern))
+}
+
+// set filter remove <id> <filterID>
+func setFilterRemoveCmd(ctx router.Context, args router.Values) error {
+	c := ctx.(*context)
+
+	sub, err := getOwnedSubscription(c, args.String("id"))
+	if err != nil || sub == nil {
+		return err
+	}
+
+	filterID, err := strconv.Atoi(args.String("filterID"))
+	if err != nil {
+		return c.Reply("`filterID` must be a number!")
+	}
+
+	if err := c.bot.c.RemoveFilter(sub.ID, filterID); err != nil {
+		return err
+	}
+	return c.Reply(fmt.Sprintf("filter #%d removed from subscription #%d.", filterID, sub.ID))
+}
+
+// set filter list <id>
+func setFilterListCmd(ctx router.Context, args router.Values) error {
+	c := ctx.(*context)
+
+	sub, err := getOwnedSubscription(c, args.String("id"))
+	if err != nil || sub == nil {
+		return err
+	}
+
+	filters, err := c.bot.c.ListFilters(sub.ID)
+	if err != nil {
+		return err
+	}
+	if len(filters) == 0 {
+		return c.Reply(fmt.Sprintf("subscription #%d has no filters.", sub.ID))
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("**Filters for subscription #%d:**\n", sub.ID))
+	for _, f := range filters {
+		b.WriteString(fmt.Sprintf("%d | %s | `%s`\n", f.ID, f.Kind, f.Pattern))
+	}
+	return c.Reply(b.String())
+}
+
+// getOwnedSubscription looks up idArg as a subscription ID and verifies it belongs to
+// the invoking guild, replying with the usual error message and returning a nil
+// subscription if either check fails.
+func getOwnedSubscription(ctx *context, idArg string) (*Subscription, error) {
+	id, err := strconv.Atoi(idArg)
+	if err != nil {
+		return nil, ctx.Reply("`id` must be a number!")
+	}
+	sub, err := ctx.bot.c.GetSubscription(id)
+	if err == sql.ErrNoRows {
+		return nil, ctx.Reply("could not find a subscription with that ID, check the list again?")
+	} else if err != nil {
+		return nil, err
+	}
+
+	if sub.GuildID != ctx.GuildID() {
+		return nil, ctx.Reply(fmt.Sprintf("subscription #%d does not exist in this guild.", id))
+	}
+	return sub, nil
+}
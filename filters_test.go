@@ -0,0 +1,71 @@
+package feedbot
+
+import "testing"
+
+func TestShouldDispatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters []Filter
+		title   string
+		summary string
+		want    bool
+	}{
+		{
+			name:    "no filters dispatches",
+			filters: nil,
+			title:   "anything",
+			want:    true,
+		},
+		{
+			name:    "exclude match drops",
+			filters: []Filter{{Kind: "exclude", Pattern: "spoiler"}},
+			title:   "big SPOILER incoming",
+			want:    false,
+		},
+		{
+			name:    "exclude no match dispatches",
+			filters: []Filter{{Kind: "exclude", Pattern: "spoiler"}},
+			title:   "nothing to see here",
+			want:    true,
+		},
+		{
+			name:    "include match dispatches",
+			filters: []Filter{{Kind: "include", Pattern: "release"}},
+			title:   "new release out now",
+			want:    true,
+		},
+		{
+			name:    "include no match drops",
+			filters: []Filter{{Kind: "include", Pattern: "release"}},
+			title:   "unrelated post",
+			want:    false,
+		},
+		{
+			name: "include match but exclude also matches drops",
+			filters: []Filter{
+				{Kind: "include", Pattern: "release"},
+				{Kind: "exclude", Pattern: "beta"},
+			},
+			title: "beta release out now",
+			want:  false,
+		},
+		{
+			name:    "regex pattern matches summary",
+			filters: []Filter{{Kind: "exclude", Pattern: "/^draft:/i"}},
+			summary: "Draft: work in progress",
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ShouldDispatch(tt.filters, tt.title, tt.summary, "")
+			if err != nil {
+				t.Fatalf("ShouldDispatch() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ShouldDispatch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package feedbot
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/pkg/errors"
+)
+
+var registerGlobalCommands = flag.Bool("register-global-commands", false,
+	"also register application commands globally on startup; per-guild registration always happens on GuildCreate")
+
+// onGuildCreate registers cmdRouter's application commands to the guild directly, so
+// new commands and option changes show up immediately instead of waiting on
+// Discord's global command cache (which can take up to an hour to propagate).
+func (bot *Bot) onGuildCreate(s *discordgo.Session, g *discordgo.GuildCreate) {
+	for _, cmd := range cmdRouter.ApplicationCommands() {
+		if _, err := s.ApplicationCommandCreate(s.State.User.ID, g.ID, cmd); err != nil {
+			l.Println(fmt.Sprintf("guild:%s registering cmd:%s err:%+v", g.ID, cmd.Name, err))
+		}
+	}
+}
+
+// registerGlobal registers cmdRouter's application commands globally. Propagation
+// can take up to an hour, so this is opt-in via -register-global-commands and meant
+// to run once rather than on every startup.
+func (bot *Bot) registerGlobal(s *discordgo.Session) error {
+	if !*registerGlobalCommands {
+		return nil
+	}
+	for _, cmd := range cmdRouter.ApplicationCommands() {
+		if _, err := s.ApplicationCommandCreate(s.State.User.ID, "", cmd); err != nil {
+			return errors.Wrapf(err, "registering global cmd:%s", cmd.Name)
+		}
+	}
+	return nil
+}
+
+// onInteractionCreate handles the Discord INTERACTION_CREATE event, routing
+// application commands through cmdRouter and, separately, button presses on a
+// paginated `list` message.
+func (bot *Bot) onInteractionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		cmdRouter.DispatchInteraction(&context{bot: bot, s: s, i: i}, i.ApplicationCommandData())
+	case discordgo.InteractionMessageComponent:
+		bot.onMessageComponent(s, i)
+	}
+}
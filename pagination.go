@@ -0,0 +1,160 @@
+package feedbot
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// listPageSize is how many subscriptions a single page of `list` output shows.
+const listPageSize = 10
+
+// paginationTTL is how long a `list` session may go without a button press before
+// feedbot removes its components, so stale sessions don't accumulate forever. Each
+// press restarts the countdown.
+const paginationTTL = 5 * time.Minute
+
+// paginationSession tracks one in-flight, paginated `list` message.
+type paginationSession struct {
+	channelID string
+	authorID  string
+	pages     []*discordgo.MessageEmbed
+	page      int
+	timer     *time.Timer
+}
+
+var (
+	paginationMu       sync.Mutex
+	paginationSessions = map[string]*paginationSession{} // messageID -> session
+)
+
+// listComponents builds the ⏮ ◀ ▶ ⏭ ✖ action row for a given page, disabling the
+// directional buttons that would be no-ops on the current page.
+func listComponents(page, total int) []discordgo.MessageComponent {
+	atStart, atEnd := page == 0, page == total-1
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{CustomID: "list:first", Label: "⏮", Style: discordgo.SecondaryButton, Disabled: atStart},
+				discordgo.Button{CustomID: "list:prev", Label: "◀", Style: discordgo.SecondaryButton, Disabled: atStart},
+				discordgo.Button{CustomID: "list:next", Label: "▶", Style: discordgo.SecondaryButton, Disabled: atEnd},
+				discordgo.Button{CustomID: "list:last", Label: "⏭", Style: discordgo.SecondaryButton, Disabled: atEnd},
+				discordgo.Button{CustomID: "list:close", Label: "✖", Style: discordgo.DangerButton},
+			},
+		},
+	}
+}
+
+// startPaginationSession registers msg as a live pagination session for authorID and
+// schedules its components to be stripped after paginationTTL.
+func startPaginationSession(s *discordgo.Session, msg *discordgo.Message, authorID string, pages []*discordgo.MessageEmbed) {
+	sess := &paginationSession{
+		channelID: msg.ChannelID,
+		authorID:  authorID,
+		pages:     pages,
+	}
+	sess.timer = time.AfterFunc(paginationTTL, func() { expirePaginationSession(s, msg.ID) })
+
+	paginationMu.Lock()
+	paginationSessions[msg.ID] = sess
+	paginationMu.Unlock()
+}
+
+// expirePaginationSession removes a session's components so its buttons stop
+// working, and drops it from the session map.
+func expirePaginationSession(s *discordgo.Session, messageID string) {
+	paginationMu.Lock()
+	sess, ok := paginationSessions[messageID]
+	delete(paginationSessions, messageID)
+	paginationMu.Unlock()
+	if !ok {
+		return
+	}
+
+	_, err := s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		Channel:    sess.channelID,
+		ID:         messageID,
+		Embeds:     []*discordgo.MessageEmbed{sess.pages[sess.page]},
+		Components: []discordgo.MessageComponent{},
+	})
+	if err != nil {
+		l.Println("err expiring list session:", err)
+	}
+}
+
+// onMessageComponent handles button presses on a paginated `list` message: it
+// authenticates the pressing user against the session's original invoker, then
+// updates the message in place via InteractionResponseUpdateMessage.
+func (bot *Bot) onMessageComponent(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.MessageComponentData()
+	if !strings.HasPrefix(data.CustomID, "list:") {
+		return
+	}
+
+	paginationMu.Lock()
+	sess, ok := paginationSessions[i.Message.ID]
+	paginationMu.Unlock()
+	if !ok {
+		s.InteractionRespond(i.Interaction, ephemeral("this menu has expired."))
+		return
+	}
+
+	if interactionMember(i).User.ID != sess.authorID {
+		s.InteractionRespond(i.Interaction, ephemeral("only the person who ran `list` can page through it."))
+		return
+	}
+
+	action := strings.TrimPrefix(data.CustomID, "list:")
+	if action == "close" {
+		sess.timer.Stop()
+		expirePaginationSession(s, i.Message.ID)
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Embeds:     []*discordgo.MessageEmbed{sess.pages[sess.page]},
+				Components: []discordgo.MessageComponent{},
+			},
+		})
+		return
+	}
+
+	paginationMu.Lock()
+	switch action {
+	case "first":
+		sess.page = 0
+	case "prev":
+		if sess.page > 0 {
+			sess.page--
+		}
+	case "next":
+		if sess.page < len(sess.pages)-1 {
+			sess.page++
+		}
+	case "last":
+		sess.page = len(sess.pages) - 1
+	}
+	page := sess.page
+	paginationMu.Unlock()
+
+	sess.timer.Reset(paginationTTL)
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{sess.pages[page]},
+			Components: listComponents(page, len(sess.pages)),
+		},
+	})
+}
+
+// ephemeral wraps a message as an interaction response only the invoking user can see.
+func ephemeral(content string) *discordgo.InteractionResponse {
+	return &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	}
+}
@@ -0,0 +1,496 @@
+// Package router describes feedbot's commands declaratively — name, subcommands,
+// typed argument specs, required permission scope, and handler — and generates both
+// the legacy text-command parser and the Discord slash-command registration payloads
+// from a single definition. It also centralizes the middleware every command needs:
+// panic recovery, privilege checking, and error-reply formatting, none of which used
+// to live anywhere but duplicated at the top of each handler.
+package router
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Context is the subset of feedbot's command context the router needs. Everything
+// else (looking up subscriptions, touching the DB, etc.) stays in feedbot's own
+// handler bodies, which type-assert back to their concrete context.
+type Context interface {
+	Reply(string) error
+}
+
+// Values holds a command's parsed arguments, keyed by Arg name, regardless of
+// whether they arrived as whitespace-separated text or typed slash-command options.
+type Values map[string]string
+
+// String returns the named argument, or "" if it wasn't supplied.
+func (v Values) String(name string) string { return v[name] }
+
+// Handler implements a command or leaf subcommand.
+type Handler func(ctx Context, args Values) error
+
+// Command is one node in the command tree: either a leaf with a Handler, or a
+// branch with Subcommands (not both).
+type Command struct {
+	Name        string
+	Description string
+	// Permission is the scope consulted via the Router's Authorizer. If empty, it
+	// defaults to the command's dotted path (e.g. "set.embed").
+	Permission string
+	// Public skips the Authorizer entirely, for commands like `help` that every
+	// user should be able to run.
+	Public      bool
+	Args        []Arg
+	Subcommands []*Command
+	Handler     Handler
+}
+
+// Authorizer decides whether ctx's invoker may run the given scope, replying with
+// a rejection message itself if not.
+type Authorizer func(ctx Context, scope string) (bool, error)
+
+// Router dispatches both legacy text commands and slash-command interactions
+// against the same declared Command tree.
+type Router struct {
+	order     []*Command
+	commands  map[string]*Command
+	authorize Authorizer
+	onError   func(path string, err error)
+	onPanic   func(path string, recovered interface{})
+}
+
+// New creates a Router. onError and onPanic may be nil, in which case failures are
+// silently swallowed after the Authorizer/Handler has had a chance to reply.
+func New(authorize Authorizer, onError func(string, error), onPanic func(string, interface{})) *Router {
+	return &Router{
+		commands:  make(map[string]*Command),
+		authorize: authorize,
+		onError:   onError,
+		onPanic:   onPanic,
+	}
+}
+
+// Register adds top-level commands to the tree.
+func (r *Router) Register(cmds ...*Command) {
+	for _, cmd := range cmds {
+		r.order = append(r.order, cmd)
+		r.commands[cmd.Name] = cmd
+	}
+}
+
+// Dispatch runs a legacy text command: name is the top-level command word, and raw
+// is whatever whitespace-separated tokens followed it, which may first address zero
+// or more levels of subcommand before the argument tokens begin.
+func (r *Router) Dispatch(ctx Context, name string, raw []string) {
+	defer r.recoverPanic(name)
+
+	cmd, ok := r.commands[name]
+	if !ok {
+		return
+	}
+
+	path := name
+	for cmd.Handler == nil {
+		if len(raw) == 0 || !r.descendTo(raw[0], &cmd, &path) {
+			ctx.Reply(fmt.Sprintf("**usage:** `%s <%s>`, see help command.", path, subcommandNames(cmd)))
+			return
+		}
+		raw = raw[1:]
+	}
+
+	values, err := parseTextArgs(cmd, raw)
+	if err != nil {
+		ctx.Reply(fmt.Sprintf("**usage:** `%s %s`", path, usageArgs(cmd.Args)))
+		return
+	}
+
+	r.invoke(ctx, cmd, path, values)
+}
+
+// descendTo moves cmd to the subcommand named word, updating path alongside it.
+// Reports whether a matching subcommand was found.
+func (r *Router) descendTo(word string, cmd **Command, path *string) bool {
+	for _, sc := range (*cmd).Subcommands {
+		if sc.Name == word {
+			*cmd = sc
+			*path = *path + "." + sc.Name
+			return true
+		}
+	}
+	return false
+}
+
+// DispatchInteraction runs a slash-command interaction, descending through
+// Discord's own subcommand-option nesting the same way Dispatch descends through
+// text tokens.
+func (r *Router) DispatchInteraction(ctx Context, data discordgo.ApplicationCommandInteractionData) {
+	defer r.recoverPanic(data.Name)
+
+	cmd, ok := r.commands[data.Name]
+	if !ok {
+		return
+	}
+
+	path := data.Name
+	opts := data.Options
+	for len(opts) == 1 && isSubcommandOption(opts[0].Type) {
+		var next *Command
+		for _, sc := range cmd.Subcommands {
+			if sc.Name == opts[0].Name {
+				next = sc
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		cmd, path, opts = next, path+"."+next.Name, opts[0].Options
+	}
+
+	if cmd.Handler == nil {
+		ctx.Reply(fmt.Sprintf("**usage:** `%s <%s>`, see help command.", path, subcommandNames(cmd)))
+		return
+	}
+
+	values := make(Values, len(opts))
+	for _, o := range opts {
+		values[o.Name] = fmt.Sprintf("%v", o.Value)
+	}
+
+	r.invoke(ctx, cmd, path, values)
+}
+
+func isSubcommandOption(t discordgo.ApplicationCommandOptionType) bool {
+	return t == discordgo.ApplicationCommandOptionSubCommand || t == discordgo.ApplicationCommandOptionSubCommandGroup
+}
+
+// invoke runs the shared privilege-check-then-handler-then-error-log sequence.
+func (r *Router) invoke(ctx Context, cmd *Command, path string, values Values) {
+	if !cmd.Public {
+		scope := cmd.Permission
+		if scope == "" {
+			scope = path
+		}
+
+		ok, err := r.authorize(ctx, scope)
+		if err != nil {
+			r.reportError(path, err)
+			return
+		}
+		if !ok {
+			return // the Authorizer already replied with a rejection message
+		}
+	}
+
+	if err := cmd.Handler(ctx, values); err != nil {
+		r.reportError(path, err)
+	}
+}
+
+func (r *Router) reportError(path string, err error) {
+	if r.onError != nil {
+		r.onError(path, err)
+	}
+}
+
+func (r *Router) recoverPanic(path string) {
+	if rec := recover(); rec != nil && r.onPanic != nil {
+		r.onPanic(path, rec)
+	}
+}
+
+// ApplicationCommands generates the Discord application-command registration
+// payloads for the whole tree, in registration order.
+func (r *Router) ApplicationCommands() []*discordgo.ApplicationCommand {
+	out := make([]*discordgo.ApplicationCommand, len(r.order))
+	for i, cmd := range r.order {
+		out[i] = &discordgo.ApplicationCommand{
+			Name:        cmd.Name,
+			Description: cmd.Description,
+			Options:     cmd.options(),
+		}
+	}
+	return out
+}
+
+func (cmd *Command) options() []*discordgo.ApplicationCommandOption {
+	if len(cmd.Subcommands) > 0 {
+		opts := make([]*discordgo.ApplicationCommandOption, len(cmd.Subcommands))
+		for i, sc := range cmd.Subcommands {
+			opts[i] = sc.asOption()
+		}
+		return opts
+	}
+	return cmd.argOptions()
+}
+
+// asOption renders cmd as the option type appropriate to its depth: a
+// SubCommandGroup if cmd is itself a branch (e.g. "filter" under "set", which has its
+// own add/remove/list), or a SubCommand if cmd is a leaf.
+func (cmd *Command) asOption() *discordgo.ApplicationCommandOption {
+	if len(cmd.Subcommands) > 0 {
+		return &discordgo.ApplicationCommandOption{
+			Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+			Name:        cmd.Name,
+			Description: cmd.Description,
+			Options:     cmd.options(),
+		}
+	}
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+		Name:        cmd.Name,
+		Description: cmd.Description,
+		Options:     cmd.argOptions(),
+	}
+}
+
+func (cmd *Command) argOptions() []*discordgo.ApplicationCommandOption {
+	opts := make([]*discordgo.ApplicationCommandOption, len(cmd.Args))
+	for i, a := range cmd.Args {
+		opts[i] = a.option()
+	}
+	return opts
+}
+
+// parseTextArgs validates raw's length against cmd's required/optional Args and
+// runs each Arg's own text parsing (e.g. stripping a `<#...>` channel mention).
+//
+// Args are consumed in order, but an optional Arg that fails to parse the current
+// token (e.g. a ChannelArg facing a user mention) is skipped rather than treated as
+// an error, so the token is tried against the next Arg instead — this is what lets
+// a command like `set contact` take a single token that may be either a user or a
+// channel mention, registered as two separate (optional) typed Args.
+func parseTextArgs(cmd *Command, raw []string) (Values, error) {
+	required := 0
+	for _, a := range cmd.Args {
+		if a.required() {
+			required++
+		}
+	}
+	if len(raw) < required || len(raw) > len(cmd.Args) {
+		return nil, fmt.Errorf("%s takes %d-%d argument(s), got %d", cmd.Name, required, len(cmd.Args), len(raw))
+	}
+
+	values := make(Values, len(raw))
+	ti := 0
+	for _, a := range cmd.Args {
+		if ti >= len(raw) {
+			break
+		}
+		parsed, err := a.parseText(raw[ti])
+		if err != nil {
+			if a.required() {
+				return nil, err
+			}
+			continue
+		}
+		values[a.argName()] = parsed
+		ti++
+	}
+	if ti < len(raw) {
+		return nil, fmt.Errorf("%s: could not parse argument %q", cmd.Name, raw[ti])
+	}
+	return values, nil
+}
+
+func subcommandNames(cmd *Command) string {
+	names := make([]string, len(cmd.Subcommands))
+	for i, sc := range cmd.Subcommands {
+		names[i] = sc.Name
+	}
+	return strings.Join(names, "|")
+}
+
+func usageArgs(args []Arg) string {
+	parts := make([]string, len(args))
+	for i, a := range args {
+		if a.required() {
+			parts[i] = fmt.Sprintf("<%s>", a.argName())
+		} else {
+			parts[i] = fmt.Sprintf("[%s]", a.argName())
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Arg is a single typed command argument: it knows how to describe itself as a
+// Discord slash-command option, and how to parse (and validate) its raw text-command
+// token.
+type Arg interface {
+	argName() string
+	required() bool
+	option() *discordgo.ApplicationCommandOption
+	parseText(raw string) (string, error)
+}
+
+// StringArg is a free-form text argument.
+type StringArg struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+func (a StringArg) argName() string { return a.Name }
+func (a StringArg) required() bool  { return a.Required }
+func (a StringArg) option() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type: discordgo.ApplicationCommandOptionString, Name: a.Name, Description: a.Description, Required: a.Required,
+	}
+}
+func (a StringArg) parseText(raw string) (string, error) { return raw, nil }
+
+// IntegerArg is a plain whole-number argument.
+type IntegerArg struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+func (a IntegerArg) argName() string { return a.Name }
+func (a IntegerArg) required() bool  { return a.Required }
+func (a IntegerArg) option() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type: discordgo.ApplicationCommandOptionInteger, Name: a.Name, Description: a.Description, Required: a.Required,
+	}
+}
+func (a IntegerArg) parseText(raw string) (string, error) {
+	if _, err := strconv.Atoi(raw); err != nil {
+		return "", fmt.Errorf("`%s` must be a number!", a.Name)
+	}
+	return raw, nil
+}
+
+// SubscriptionArg is sugar for an IntegerArg identifying a subscription. RequireGuildMatch
+// documents that the handler is expected to verify the resolved subscription belongs
+// to the invoking guild; the router has no notion of a database, so it can't enforce
+// that itself.
+type SubscriptionArg struct {
+	Name              string
+	Required          bool
+	RequireGuildMatch bool
+}
+
+func (a SubscriptionArg) argName() string { return a.Name }
+func (a SubscriptionArg) required() bool  { return a.Required }
+func (a SubscriptionArg) option() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type: discordgo.ApplicationCommandOptionInteger, Name: a.Name, Description: "subscription ID", Required: a.Required,
+	}
+}
+func (a SubscriptionArg) parseText(raw string) (string, error) {
+	if _, err := strconv.Atoi(raw); err != nil {
+		return "", fmt.Errorf("`%s` must be a number!", a.Name)
+	}
+	return raw, nil
+}
+
+// ChannelArg resolves to a Discord channel ID, whether given as a slash-command
+// channel picker or a `<#id>` text mention.
+type ChannelArg struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+func (a ChannelArg) argName() string { return a.Name }
+func (a ChannelArg) required() bool  { return a.Required }
+func (a ChannelArg) option() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type: discordgo.ApplicationCommandOptionChannel, Name: a.Name, Description: a.Description, Required: a.Required,
+	}
+}
+func (a ChannelArg) parseText(raw string) (string, error) {
+	if len(raw) < 4 || raw[0] != '<' || raw[1] != '#' || raw[len(raw)-1] != '>' {
+		return "", fmt.Errorf("when specifying a channel, please use a #channel mention!")
+	}
+	return raw[2 : len(raw)-1], nil
+}
+
+// UserArg resolves to a Discord user ID, whether given as a slash-command user
+// picker or a `<@id>`/`<@!id>` text mention.
+type UserArg struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+func (a UserArg) argName() string { return a.Name }
+func (a UserArg) required() bool  { return a.Required }
+func (a UserArg) option() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type: discordgo.ApplicationCommandOptionUser, Name: a.Name, Description: a.Description, Required: a.Required,
+	}
+}
+func (a UserArg) parseText(raw string) (string, error) {
+	id := raw
+	if len(raw) >= 3 && raw[0] == '<' && raw[1] == '@' && raw[len(raw)-1] == '>' {
+		id = strings.TrimPrefix(raw[2:len(raw)-1], "!")
+	} else if _, err := strconv.Atoi(raw); err != nil {
+		return "", fmt.Errorf("please use a user mention or user ID!")
+	}
+	return id, nil
+}
+
+// MentionableArg resolves to a Discord user or role ID, whether given as a
+// slash-command mentionable picker or a `<@id>`/`<@!id>`/`<@&id>` text mention — for
+// arguments like an ACL principal, where a sibling Arg (not the router) decides
+// whether the ID names a role or a user.
+type MentionableArg struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+func (a MentionableArg) argName() string { return a.Name }
+func (a MentionableArg) required() bool  { return a.Required }
+func (a MentionableArg) option() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type: discordgo.ApplicationCommandOptionMentionable, Name: a.Name, Description: a.Description, Required: a.Required,
+	}
+}
+func (a MentionableArg) parseText(raw string) (string, error) {
+	if mentionableTextRegex.MatchString(raw) {
+		id := strings.TrimPrefix(raw[1:len(raw)-1], "@")
+		id = strings.TrimPrefix(id, "&")
+		id = strings.TrimPrefix(id, "!")
+		return id, nil
+	}
+	if _, err := strconv.Atoi(raw); err == nil {
+		return raw, nil
+	}
+	return "", fmt.Errorf("please use a role or user mention, or a numeric ID!")
+}
+
+var mentionableTextRegex = regexp.MustCompile(`^<@[&!]?\d+>$`)
+
+// EnumArg restricts the argument to one of a fixed set of choices, rendered as a
+// dropdown in the slash-command UI.
+type EnumArg struct {
+	Name        string
+	Description string
+	Required    bool
+	Choices     []string
+}
+
+func (a EnumArg) argName() string { return a.Name }
+func (a EnumArg) required() bool  { return a.Required }
+func (a EnumArg) option() *discordgo.ApplicationCommandOption {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, len(a.Choices))
+	for i, c := range a.Choices {
+		choices[i] = &discordgo.ApplicationCommandOptionChoice{Name: c, Value: c}
+	}
+	return &discordgo.ApplicationCommandOption{
+		Type: discordgo.ApplicationCommandOptionString, Name: a.Name, Description: a.Description, Required: a.Required, Choices: choices,
+	}
+}
+func (a EnumArg) parseText(raw string) (string, error) {
+	for _, c := range a.Choices {
+		if raw == c {
+			return raw, nil
+		}
+	}
+	return "", fmt.Errorf("`%s` must be one of %s", a.Name, strings.Join(a.Choices, "|"))
+}
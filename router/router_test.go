@@ -0,0 +1,254 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type fakeContext struct {
+	replies []string
+}
+
+func (f *fakeContext) Reply(m string) error {
+	f.replies = append(f.replies, m)
+	return nil
+}
+
+func allow(ctx Context, scope string) (bool, error) { return true, nil }
+
+func deny(ctx Context, scope string) (bool, error) {
+	ctx.Reply("denied")
+	return false, nil
+}
+
+func testRouter(authorize Authorizer) (*Router, *Values) {
+	var got Values
+	rt := New(authorize, nil, nil)
+	rt.Register(&Command{
+		Name: "set",
+		Subcommands: []*Command{
+			{
+				Name: "filter",
+				Subcommands: []*Command{
+					{
+						Name: "add",
+						Args: []Arg{
+							SubscriptionArg{Name: "id", Required: true},
+							StringArg{Name: "pattern", Required: true},
+						},
+						Handler: func(ctx Context, args Values) error {
+							got = args
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name: "embed",
+				Args: []Arg{EnumArg{Name: "value", Choices: []string{"on", "off"}, Required: true}},
+				Handler: func(ctx Context, args Values) error {
+					got = args
+					return nil
+				},
+			},
+		},
+	})
+	return rt, &got
+}
+
+func TestDispatchDescendsSubcommands(t *testing.T) {
+	rt, got := testRouter(allow)
+	ctx := &fakeContext{}
+
+	rt.Dispatch(ctx, "set", []string{"filter", "add", "7", "breaking"})
+
+	if (*got)["id"] != "7" || (*got)["pattern"] != "breaking" {
+		t.Fatalf("handler got %v", *got)
+	}
+}
+
+func TestDispatchRejectsUnauthorized(t *testing.T) {
+	rt, got := testRouter(deny)
+	ctx := &fakeContext{}
+
+	rt.Dispatch(ctx, "set", []string{"embed", "on"})
+
+	if *got != nil {
+		t.Fatalf("handler should not have run, got %v", *got)
+	}
+	if len(ctx.replies) != 1 || ctx.replies[0] != "denied" {
+		t.Fatalf("expected a single denial reply, got %v", ctx.replies)
+	}
+}
+
+func TestDispatchUsageOnIncompleteSubcommand(t *testing.T) {
+	rt, got := testRouter(allow)
+	ctx := &fakeContext{}
+
+	rt.Dispatch(ctx, "set", []string{"filter"})
+
+	if *got != nil {
+		t.Fatalf("handler should not have run, got %v", *got)
+	}
+	if len(ctx.replies) != 1 {
+		t.Fatalf("expected a usage reply, got %v", ctx.replies)
+	}
+}
+
+// TestApplicationCommandsNestsSubcommandGroups guards against a regression where a
+// branch subcommand (one with its own Subcommands, like "filter" under "set") was
+// rendered with zero options instead of as a SubCommandGroup wrapping its children.
+func TestApplicationCommandsNestsSubcommandGroups(t *testing.T) {
+	rt, _ := testRouter(allow)
+	cmds := rt.ApplicationCommands()
+	if len(cmds) != 1 {
+		t.Fatalf("expected 1 top-level command, got %d", len(cmds))
+	}
+
+	var filterGroup *discordgo.ApplicationCommandOption
+	for _, opt := range cmds[0].Options {
+		if opt.Name == "filter" {
+			filterGroup = opt
+		}
+	}
+	if filterGroup == nil {
+		t.Fatal("expected a \"filter\" option under \"set\"")
+	}
+	if filterGroup.Type != discordgo.ApplicationCommandOptionSubCommandGroup {
+		t.Fatalf("expected filter to be a SubCommandGroup, got %v", filterGroup.Type)
+	}
+	if len(filterGroup.Options) != 1 || filterGroup.Options[0].Name != "add" {
+		t.Fatalf("expected filter's group to contain \"add\", got %v", filterGroup.Options)
+	}
+}
+
+// TestDispatchInteractionDescendsSubcommandGroups exercises the same nesting through
+// the slash-command path, including the two-level SubCommandGroup -> SubCommand
+// descent.
+func TestDispatchInteractionDescendsSubcommandGroups(t *testing.T) {
+	rt, got := testRouter(allow)
+	ctx := &fakeContext{}
+
+	data := discordgo.ApplicationCommandInteractionData{
+		Name: "set",
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{{
+			Name: "filter",
+			Type: discordgo.ApplicationCommandOptionSubCommandGroup,
+			Options: []*discordgo.ApplicationCommandInteractionDataOption{{
+				Name: "add",
+				Type: discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{
+					{Name: "id", Value: "7"},
+					{Name: "pattern", Value: "breaking"},
+				},
+			}},
+		}},
+	}
+
+	rt.DispatchInteraction(ctx, data)
+
+	if (*got)["id"] != "7" || (*got)["pattern"] != "breaking" {
+		t.Fatalf("handler got %v", *got)
+	}
+}
+
+// TestDispatchInteractionStopsAtBranch guards against invoking a nil Handler when an
+// interaction's options don't descend all the way to a leaf command.
+func TestDispatchInteractionStopsAtBranch(t *testing.T) {
+	rt, got := testRouter(allow)
+	ctx := &fakeContext{}
+
+	data := discordgo.ApplicationCommandInteractionData{
+		Name: "set",
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{{
+			Name: "filter",
+			Type: discordgo.ApplicationCommandOptionSubCommandGroup,
+		}},
+	}
+
+	rt.DispatchInteraction(ctx, data)
+
+	if *got != nil {
+		t.Fatalf("handler should not have run, got %v", *got)
+	}
+	if len(ctx.replies) != 1 {
+		t.Fatalf("expected a usage reply instead of a panic, got %v", ctx.replies)
+	}
+}
+
+// TestParseTextArgsSkipsNonMatchingOptionalArg covers the `set contact` shape: a
+// single text token that may satisfy either of two optional Args (e.g. a channel
+// mention facing a UserArg first in the list) should fall through to whichever Arg
+// actually parses it, rather than erroring out.
+func TestParseTextArgsSkipsNonMatchingOptionalArg(t *testing.T) {
+	cmd := &Command{
+		Name: "contact",
+		Args: []Arg{
+			UserArg{Name: "user"},
+			ChannelArg{Name: "channel"},
+		},
+	}
+
+	values, err := parseTextArgs(cmd, []string{"<#123>"})
+	if err != nil {
+		t.Fatalf("parseTextArgs() error = %v", err)
+	}
+	if values["channel"] != "123" || values["user"] != "" {
+		t.Fatalf("parseTextArgs() = %v, want channel=123", values)
+	}
+
+	values, err = parseTextArgs(cmd, []string{"<@456>"})
+	if err != nil {
+		t.Fatalf("parseTextArgs() error = %v", err)
+	}
+	if values["user"] != "456" || values["channel"] != "" {
+		t.Fatalf("parseTextArgs() = %v, want user=456", values)
+	}
+}
+
+func TestParseTextArgsRejectsUnparseableToken(t *testing.T) {
+	cmd := &Command{
+		Name: "contact",
+		Args: []Arg{
+			UserArg{Name: "user"},
+			ChannelArg{Name: "channel"},
+		},
+	}
+
+	if _, err := parseTextArgs(cmd, []string{"not-a-mention"}); err == nil {
+		t.Fatal("expected an error for a token matching neither optional Arg")
+	}
+}
+
+func TestMentionableArgParseText(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{raw: "<@123>", want: "123"},
+		{raw: "<@!123>", want: "123"},
+		{raw: "<@&123>", want: "123"},
+		{raw: "123", want: "123"},
+		{raw: "not-a-mention", wantErr: true},
+	}
+
+	var a MentionableArg
+	for _, tt := range tests {
+		got, err := a.parseText(tt.raw)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseText(%q) expected an error, got %q", tt.raw, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseText(%q) error = %v", tt.raw, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseText(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
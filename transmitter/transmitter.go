@@ -0,0 +1,221 @@
+// Package transmitter posts feed updates to Discord over per-channel webhooks
+// instead of the bot's own user, and keeps enough state to edit or delete a post
+// when the underlying feed item changes or disappears.
+package transmitter
+
+import (
+	"log"
+	"os"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/pkg/errors"
+)
+
+var l = log.New(os.Stderr, "transmitter: ", log.LstdFlags)
+
+// cacheSize bounds how many (subscription, feed item) -> message mappings are kept
+// in memory. Entries are keyed per-subscription, so this comfortably covers many
+// guilds' worth of recently-seen items without unbounded growth.
+const cacheSize = 4096
+
+// Store persists the posted-message cache across restarts. It's implemented by the
+// bot's DB client.
+type Store interface {
+	SaveWebhookPosts(posts []Post) error
+	LoadWebhookPosts() ([]Post, error)
+}
+
+// Post records a single feed item's webhook-posted message, so a later re-poll can
+// edit or delete it instead of posting a duplicate.
+type Post struct {
+	SubscriptionID int
+	ItemGUID       string
+	MessageID      string
+	ContentHash    string
+	MissedCycles   int
+}
+
+type postKey struct {
+	subscriptionID int
+	itemGUID       string
+}
+
+// Transmitter posts feed items to Discord via per-channel webhooks, editing or
+// deleting the corresponding message as items change or age out of the feed.
+type Transmitter struct {
+	s     *discordgo.Session
+	store Store
+
+	mu       sync.Mutex
+	posts    *lru.Cache                    // postKey -> *Post
+	webhooks map[string]*discordgo.Webhook // channelID -> webhook
+}
+
+// New creates a Transmitter and restores its posted-message cache from the store.
+func New(s *discordgo.Session, store Store) (*Transmitter, error) {
+	posts, err := lru.New(cacheSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "err creating post cache")
+	}
+
+	t := &Transmitter{
+		s:        s,
+		store:    store,
+		posts:    posts,
+		webhooks: make(map[string]*discordgo.Webhook),
+	}
+
+	saved, err := store.LoadWebhookPosts()
+	if err != nil {
+		return nil, errors.Wrap(err, "err loading webhook posts")
+	}
+	for _, p := range saved {
+		p := p
+		t.posts.Add(postKey{p.SubscriptionID, p.ItemGUID}, &p)
+	}
+
+	return t, nil
+}
+
+// webhookFor returns the channel's feedbot-managed webhook, discovering an existing
+// one or creating it if this is the first time we've posted here.
+func (t *Transmitter) webhookFor(channelID string) (*discordgo.Webhook, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if wh, ok := t.webhooks[channelID]; ok {
+		return wh, nil
+	}
+
+	existing, err := t.s.ChannelWebhooks(channelID)
+	if err != nil {
+		return nil, errors.Wrap(err, "err listing channel webhooks")
+	}
+	for _, wh := range existing {
+		if wh.Name == webhookName {
+			t.webhooks[channelID] = wh
+			return wh, nil
+		}
+	}
+
+	wh, err := t.s.WebhookCreate(channelID, webhookName, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "err creating webhook")
+	}
+	t.webhooks[channelID] = wh
+	return wh, nil
+}
+
+const webhookName = "feedbot"
+
+// Item is the subset of a feed entry the transmitter needs in order to post, edit,
+// or de-duplicate it.
+type Item struct {
+	GUID        string
+	ContentHash string
+	Title       string
+	URL         string
+	Summary     string
+}
+
+// Send posts item to channelID via the channel's webhook, or, if this item was
+// already posted and its content hash changed, edits the existing message in place.
+func (t *Transmitter) Send(subscriptionID int, channelID string, item Item, embed *discordgo.MessageEmbed) error {
+	wh, err := t.webhookFor(channelID)
+	if err != nil {
+		return err
+	}
+
+	key := postKey{subscriptionID, item.GUID}
+	if cached, ok := t.posts.Get(key); ok {
+		p := cached.(*Post)
+		p.MissedCycles = 0
+		if p.ContentHash == item.ContentHash {
+			return nil
+		}
+
+		content := item.Title + "\n" + item.URL
+		edit := &discordgo.WebhookEdit{Content: &content}
+		if embed != nil {
+			edit.Embeds = &[]*discordgo.MessageEmbed{embed}
+		}
+		if _, err := t.s.WebhookMessageEdit(wh.ID, wh.Token, p.MessageID, edit); err != nil {
+			return errors.Wrap(err, "err editing webhook message")
+		}
+		p.ContentHash = item.ContentHash
+		return nil
+	}
+
+	params := &discordgo.WebhookParams{Content: item.Title + "\n" + item.URL}
+	if embed != nil {
+		params.Embeds = []*discordgo.MessageEmbed{embed}
+	}
+	msg, err := t.s.WebhookExecute(wh.ID, wh.Token, true, params)
+	if err != nil {
+		return errors.Wrap(err, "err posting webhook message")
+	}
+
+	t.posts.Add(key, &Post{
+		SubscriptionID: subscriptionID,
+		ItemGUID:       item.GUID,
+		MessageID:      msg.ID,
+		ContentHash:    item.ContentHash,
+	})
+	return nil
+}
+
+// maxMissedCycles is how many consecutive polls an item may be absent from its feed
+// before the transmitter deletes the corresponding message.
+const maxMissedCycles = 3
+
+// Sweep deletes messages for any cached item that has now been absent from its feed
+// for maxMissedCycles consecutive polls. Call this once per poll, after Send has been
+// called for every item still present in the feed.
+func (t *Transmitter) Sweep(subscriptionID int, channelID string, presentGUIDs map[string]bool) error {
+	wh, err := t.webhookFor(channelID)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range t.posts.Keys() {
+		key := k.(postKey)
+		if key.subscriptionID != subscriptionID {
+			continue
+		}
+		if presentGUIDs[key.itemGUID] {
+			continue
+		}
+
+		cached, ok := t.posts.Get(key)
+		if !ok {
+			continue
+		}
+		p := cached.(*Post)
+		p.MissedCycles++
+		if p.MissedCycles < maxMissedCycles {
+			continue
+		}
+
+		if err := t.s.WebhookMessageDelete(wh.ID, wh.Token, p.MessageID); err != nil {
+			l.Println("err deleting webhook message:", err)
+		}
+		t.posts.Remove(key)
+	}
+	return nil
+}
+
+// Close persists the posted-message cache so it survives a restart.
+func (t *Transmitter) Close() error {
+	keys := t.posts.Keys()
+	posts := make([]Post, 0, len(keys))
+	for _, k := range keys {
+		cached, ok := t.posts.Get(k)
+		if !ok {
+			continue
+		}
+		posts = append(posts, *cached.(*Post))
+	}
+	return t.store.SaveWebhookPosts(posts)
+}
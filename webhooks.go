@@ -0,0 +1,44 @@
+package feedbot
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/fsnakes/feedbot/transmitter"
+)
+
+// tx is the shared webhook transmitter, constructed once the bot is ready (see
+// onReady) and the DB client — which doubles as transmitter.Store — is available.
+var tx *transmitter.Transmitter
+
+// DispatchWebhook posts item to sub's channel via the webhook transmitter, or edits
+// the existing message in place if item's content hash has changed since the last
+// poll. It is a no-op if webhooks aren't enabled for sub, or if the transmitter
+// hasn't been constructed yet.
+//
+// The poller should call this once per still-present feed item, after ShouldDispatch
+// has passed it, and follow up with SweepWebhooks once per poll so items that age out
+// of the feed get their messages cleaned up.
+func DispatchWebhook(gc *GuildConfig, sub *Subscription, item transmitter.Item, embed *discordgo.MessageEmbed) error {
+	if tx == nil || !webhooksEnabled(gc, sub) {
+		return nil
+	}
+	return tx.Send(sub.ID, sub.ChannelID, item, embed)
+}
+
+// SweepWebhooks deletes messages for any previously-posted item that has aged out of
+// sub's feed. presentGUIDs should contain every item GUID seen in the feed's current
+// poll.
+func SweepWebhooks(sub *Subscription, presentGUIDs map[string]bool) error {
+	if tx == nil {
+		return nil
+	}
+	return tx.Sweep(sub.ID, sub.ChannelID, presentGUIDs)
+}
+
+// webhooksEnabled resolves sub's effective webhook setting: its own overwrite if one
+// is set, otherwise the guild-wide default.
+func webhooksEnabled(gc *GuildConfig, sub *Subscription) bool {
+	if sub.Overwrite.Webhooks.Valid {
+		return sub.Overwrite.Webhooks.Bool
+	}
+	return gc.Webhooks
+}